@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxBatchWorkers bounds the number of requests from a single batch that
+// are dispatched to the Lambda URL concurrently.
+const maxBatchWorkers = 8
+
+// isNotification reports whether req is a JSON-RPC notification, i.e. a
+// request with no id. Notifications are executed but never produce a
+// response entry.
+func isNotification(req *JSONRPCRequest) bool {
+	return req.ID == nil
+}
+
+// invalidBatchResponse is the single response emitted when a batch is
+// empty or fails to decode, per the JSON-RPC 2.0 spec.
+func invalidBatchResponse() *JSONRPCResponse {
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      nil,
+		Error: &JSONRPCError{
+			Code:    -32600,
+			Message: "Invalid Request",
+		},
+	}
+}
+
+// HandleBatch dispatches reqs individually against the Lambda URL, bounded
+// by maxBatchWorkers concurrent in-flight calls, and returns the responses
+// in the same order as reqs with notifications omitted.
+func (c *Client) HandleBatch(reqs []*JSONRPCRequest) []*JSONRPCResponse {
+	if len(reqs) == 0 {
+		return []*JSONRPCResponse{invalidBatchResponse()}
+	}
+
+	results := make([]*JSONRPCResponse, len(reqs))
+	sem := make(chan struct{}, maxBatchWorkers)
+	var wg sync.WaitGroup
+
+	for i, req := range reqs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, req *JSONRPCRequest) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = c.HandleRequest(req)
+		}(i, req)
+	}
+
+	wg.Wait()
+
+	responses := make([]*JSONRPCResponse, 0, len(reqs))
+	for i, req := range reqs {
+		if isNotification(req) {
+			continue
+		}
+		responses = append(responses, results[i])
+	}
+
+	return responses
+}
+
+// HandleBatchHTTP dispatches reqs as a JSON-RPC batch. It first tries to
+// send the whole batch as a single HTTP POST to the Lambda URL; if that
+// fails (network error, non-2xx status, or a body that doesn't decode as
+// a JSON array) it falls back to HandleBatch, which fans out one POST per
+// request and re-aggregates the responses. Once the Lambda URL has
+// explicitly rejected a batch (see postBatch), that's remembered on the
+// Client and later calls skip the single-POST attempt entirely, since a
+// server that doesn't understand batches won't start understanding them
+// mid-session.
+func (c *Client) HandleBatchHTTP(reqs []*JSONRPCRequest) []*JSONRPCResponse {
+	if len(reqs) == 0 {
+		return []*JSONRPCResponse{invalidBatchResponse()}
+	}
+
+	if atomic.LoadInt32(&c.batchUnsupported) == 0 {
+		if responses, ok := c.postBatch(reqs); ok {
+			return responses
+		}
+	}
+
+	return c.HandleBatch(reqs)
+}
+
+// postBatch attempts to send reqs as a single JSON array POST. ok is false
+// if the request couldn't be sent or the server didn't return a decodable
+// batch response, signaling that the caller should fall back to fan-out.
+// When the server responds but explicitly rejects the batch shape (a 4xx/5xx
+// status, or a body that doesn't decode as a JSON array), c.batchUnsupported
+// is set so HandleBatchHTTP stops retrying the single-POST path on future
+// calls; a request that never reached the server (marshal or network error)
+// says nothing about the server's capability, so it isn't recorded. Like
+// HandleRequest, it negotiates gzip (skipped when the client's timeout is
+// too tight for a clean decode) and records tracing, metrics, and logging
+// for every non-notification request in the batch, so calls that take this
+// fast path are instrumented just as the single-request path is.
+func (c *Client) postBatch(reqs []*JSONRPCRequest) (responses []*JSONRPCResponse, ok bool) {
+	body, err := json.Marshal(reqs)
+	if err != nil {
+		return nil, false
+	}
+
+	httpReq, err := http.NewRequest("POST", c.lambdaURL, bytes.NewBuffer(body))
+	if err != nil {
+		return nil, false
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	useGzip := c.client.Timeout == 0 || c.client.Timeout >= gzipMinTimeout
+	if useGzip {
+		httpReq.Header.Set("Accept-Encoding", "gzip")
+	} else {
+		httpReq.Header.Set("Accept-Encoding", "identity")
+	}
+
+	start := time.Now()
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, false
+	}
+	defer resp.Body.Close()
+	elapsed := time.Since(start)
+
+	if resp.StatusCode >= 400 {
+		atomic.StoreInt32(&c.batchUnsupported, 1)
+		return nil, false
+	}
+
+	respBody := io.Reader(resp.Body)
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, false
+		}
+		defer gz.Close()
+		respBody = gz
+	}
+
+	var batchResp []*JSONRPCResponse
+	if err := json.NewDecoder(respBody).Decode(&batchResp); err != nil {
+		atomic.StoreInt32(&c.batchUnsupported, 1)
+		return nil, false
+	}
+
+	c.recordBatchObservability(reqs, batchResp, elapsed)
+
+	return batchResp, true
+}
+
+// recordBatchObservability instruments every non-notification request in a
+// batch dispatched via postBatch's single POST, matching each by id to its
+// response. All requests in the batch share the same measured round-trip
+// elapsed, since the batch traveled over one HTTP call rather than one per
+// request.
+func (c *Client) recordBatchObservability(reqs []*JSONRPCRequest, responses []*JSONRPCResponse, elapsed time.Duration) {
+	byID := make(map[string]*JSONRPCResponse, len(responses))
+	for _, resp := range responses {
+		byID[idKey(resp.ID)] = resp
+	}
+
+	for _, req := range reqs {
+		if isNotification(req) {
+			continue
+		}
+		resp, ok := byID[idKey(req.ID)]
+		if !ok {
+			continue
+		}
+
+		span, _ := startSpan(req.Method)
+		c.recordObservability(req, resp, span, elapsed)
+	}
+}
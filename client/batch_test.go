@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandleBatch_PreservesIDsAndSkipsNotifications(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req JSONRPCRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		json.NewEncoder(w).Encode(JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Result:  req.Method,
+		})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, 1*time.Second)
+	reqs := []*JSONRPCRequest{
+		{JSONRPC: "2.0", ID: 1, Method: "first"},
+		{JSONRPC: "2.0", Method: "notify"}, // no id: notification
+		{JSONRPC: "2.0", ID: 2, Method: "second"},
+	}
+
+	resps := client.HandleBatch(reqs)
+
+	if len(resps) != 2 {
+		t.Fatalf("Expected 2 responses (notification excluded), got %d", len(resps))
+	}
+	if resps[0].ID != 1.0 || resps[0].Result != "first" {
+		t.Errorf("Expected id 1/result 'first', got id %v/result %v", resps[0].ID, resps[0].Result)
+	}
+	if resps[1].ID != 2.0 || resps[1].Result != "second" {
+		t.Errorf("Expected id 2/result 'second', got id %v/result %v", resps[1].ID, resps[1].Result)
+	}
+}
+
+func TestHandleBatch_Empty(t *testing.T) {
+	client := NewClient("http://example.com", 1*time.Second)
+
+	resps := client.HandleBatch(nil)
+
+	if len(resps) != 1 {
+		t.Fatalf("Expected a single error response, got %d", len(resps))
+	}
+	if resps[0].Error == nil || resps[0].Error.Code != -32600 {
+		t.Errorf("Expected error code -32600, got %v", resps[0].Error)
+	}
+	if resps[0].ID != nil {
+		t.Errorf("Expected id nil, got %v", resps[0].ID)
+	}
+}
+
+func TestHandleBatchHTTP_InstrumentsRequestsOnTheFastPath(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqs []JSONRPCRequest
+		json.NewDecoder(r.Body).Decode(&reqs)
+
+		var resps []JSONRPCResponse
+		for _, req := range reqs {
+			if req.ID == nil {
+				continue // notification: no response entry
+			}
+			resps = append(resps, JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: req.Method})
+		}
+		json.NewEncoder(w).Encode(resps)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, 1*time.Second)
+	metrics := NewMetrics()
+	client.metrics = metrics
+
+	reqs := []*JSONRPCRequest{
+		{JSONRPC: "2.0", ID: 1, Method: "first"},
+		{JSONRPC: "2.0", Method: "notify"},
+		{JSONRPC: "2.0", ID: 2, Method: "second"},
+	}
+
+	resps := client.HandleBatchHTTP(reqs)
+	if len(resps) != 2 {
+		t.Fatalf("Expected 2 responses, got %d", len(resps))
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	if !strings.Contains(body, `opencontext_requests_total{method="first",code="0"} 1`) {
+		t.Errorf("Expected the batch fast path to record metrics for 'first', got body:\n%s", body)
+	}
+	if !strings.Contains(body, `opencontext_requests_total{method="second",code="0"} 1`) {
+		t.Errorf("Expected the batch fast path to record metrics for 'second', got body:\n%s", body)
+	}
+}
+
+func TestHandleBatchHTTP_NegotiatesGzip(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Errorf("Expected Accept-Encoding: gzip, got %q", r.Header.Get("Accept-Encoding"))
+		}
+
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		json.NewEncoder(gz).Encode([]JSONRPCResponse{{JSONRPC: "2.0", ID: 1.0, Result: "zipped"}})
+		gz.Close()
+
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(buf.Bytes())
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, 5*time.Second)
+	resps := client.HandleBatchHTTP([]*JSONRPCRequest{{JSONRPC: "2.0", ID: 1, Method: "test"}})
+
+	if len(resps) != 1 || resps[0].Result != "zipped" {
+		t.Fatalf("Expected a single result 'zipped', got %+v", resps)
+	}
+}
+
+func TestHandleBatchHTTP_FallsBackWhenServerRejectsBatch(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var raw json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			t.Fatalf("Failed to decode body: %v", err)
+		}
+
+		if raw[0] == '[' {
+			// Server doesn't support batch requests.
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		calls++
+		var req JSONRPCRequest
+		json.Unmarshal(raw, &req)
+		json.NewEncoder(w).Encode(JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: "ok"})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, 1*time.Second)
+	reqs := []*JSONRPCRequest{
+		{JSONRPC: "2.0", ID: 1, Method: "a"},
+		{JSONRPC: "2.0", ID: 2, Method: "b"},
+	}
+
+	resps := client.HandleBatchHTTP(reqs)
+
+	if calls != 2 {
+		t.Errorf("Expected fallback to fan out 2 individual calls, got %d", calls)
+	}
+	if len(resps) != 2 {
+		t.Fatalf("Expected 2 responses, got %d", len(resps))
+	}
+}
+
+func TestHandleBatchHTTP_RemembersRejectionAndStopsRetryingBatchShape(t *testing.T) {
+	batchAttempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var raw json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+			t.Fatalf("Failed to decode body: %v", err)
+		}
+
+		if raw[0] == '[' {
+			batchAttempts++
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		var req JSONRPCRequest
+		json.Unmarshal(raw, &req)
+		json.NewEncoder(w).Encode(JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: "ok"})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, 1*time.Second)
+	reqs := []*JSONRPCRequest{{JSONRPC: "2.0", ID: 1, Method: "a"}}
+
+	client.HandleBatchHTTP(reqs)
+	client.HandleBatchHTTP(reqs)
+
+	if batchAttempts != 1 {
+		t.Errorf("Expected only the first call to attempt the single-POST batch shape, got %d attempts", batchAttempts)
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// Handler processes inbound JSON-RPC requests and notifications received on
+// a Conn. Implementations should respect ctx cancellation, which fires when
+// a matching $/cancelRequest notification arrives for a call in progress.
+type Handler interface {
+	Handle(ctx context.Context, method string, params interface{}) (result interface{}, err *JSONRPCError)
+}
+
+// BatchHandler is an optional interface a Handler may implement to process
+// a JSON-RPC batch as a unit, e.g. forwarding it upstream as a single HTTP
+// call instead of dispatching each request through Handle individually.
+type BatchHandler interface {
+	HandleBatch(reqs []*JSONRPCRequest) []*JSONRPCResponse
+}
+
+// clientHandler bridges inbound stdio requests to the Lambda HTTP backend.
+type clientHandler struct {
+	client *Client
+}
+
+func (h clientHandler) Handle(_ context.Context, method string, params interface{}) (interface{}, *JSONRPCError) {
+	resp := h.client.HandleRequest(&JSONRPCRequest{JSONRPC: "2.0", Method: method, Params: params})
+	return resp.Result, resp.Error
+}
+
+func (h clientHandler) HandleBatch(reqs []*JSONRPCRequest) []*JSONRPCResponse {
+	return h.client.HandleBatchHTTP(reqs)
+}
+
+// cancelParams is the payload of a $/cancelRequest notification.
+type cancelParams struct {
+	ID interface{} `json:"id"`
+}
+
+// Conn is a full-duplex, line-delimited JSON-RPC 2.0 connection: either
+// side may send requests, notifications, and responses to the other. It is
+// modeled on the minimal jsonrpc2 connections used by Go tooling (e.g.
+// gopls' internal/jsonrpc2), adapted to OpenContext's line-per-message
+// stdio framing.
+type Conn struct {
+	r io.Reader
+	w io.Writer
+
+	handler Handler
+	nextID  int64
+
+	mu       sync.Mutex
+	pending  map[string]chan *JSONRPCResponse
+	handling map[string]context.CancelFunc
+
+	writeMu sync.Mutex
+
+	closed chan struct{}
+	once   sync.Once
+}
+
+// NewConn creates a Conn that reads inbound messages from r and writes
+// outbound messages to w, dispatching inbound calls to handler.
+func NewConn(r io.Reader, w io.Writer, handler Handler) *Conn {
+	return &Conn{
+		r:        r,
+		w:        w,
+		handler:  handler,
+		pending:  make(map[string]chan *JSONRPCResponse),
+		handling: make(map[string]context.CancelFunc),
+		closed:   make(chan struct{}),
+	}
+}
+
+// idKey returns a stable map key for a JSON-RPC id, which may decode as a
+// string, a float64 (JSON numbers), or nil depending on the wire value.
+func idKey(id interface{}) string {
+	return fmt.Sprintf("%v", id)
+}
+
+// Run reads lines from the connection until EOF or a read error, dispatching
+// each as a batch, a request/notification, or a response to one of our own
+// pending calls. It blocks until the stream closes, at which point every
+// pending Call returns an error naming its method; the read error that
+// ended the stream is not wrapped in that error.
+func (c *Conn) Run() error {
+	scanner := bufio.NewScanner(c.r)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		c.dispatchLine(append([]byte(nil), line...))
+	}
+
+	readErr := scanner.Err()
+	c.closeWithError(fmt.Errorf("connection closed: %w", firstNonNil(readErr, io.EOF)))
+	return readErr
+}
+
+func firstNonNil(err, fallback error) error {
+	if err != nil {
+		return err
+	}
+	return fallback
+}
+
+// dispatchLine routes a single inbound line to the batch path, the
+// response path, or the request/notification path.
+func (c *Conn) dispatchLine(line []byte) {
+	if line[0] == '[' {
+		// Dispatched in its own goroutine, like handleInbound, so a large or
+		// slow batch (HandleBatch can block on up to len(reqs) HTTP calls)
+		// doesn't stall the read loop and delay a $/cancelRequest or another
+		// call behind it.
+		go c.dispatchBatch(line)
+		return
+	}
+
+	var peek struct {
+		Method *string     `json:"method"`
+		ID     interface{} `json:"id"`
+	}
+	if err := json.Unmarshal(line, &peek); err != nil {
+		c.writeMessage(&JSONRPCResponse{
+			JSONRPC: "2.0",
+			Error: &JSONRPCError{
+				Code:    -32700, // Parse error
+				Message: "Parse error",
+				Data:    err.Error(),
+			},
+		})
+		return
+	}
+
+	if peek.Method == nil {
+		// No method: this is a response to a call we made.
+		var resp JSONRPCResponse
+		if err := json.Unmarshal(line, &resp); err == nil {
+			c.deliver(&resp)
+		}
+		return
+	}
+
+	var req JSONRPCRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		return
+	}
+	c.handleInbound(&req)
+}
+
+// dispatchBatch decodes line as a JSON-RPC batch and writes the aggregated
+// response array, using the handler's BatchHandler implementation when
+// available and falling back to dispatching each request individually.
+func (c *Conn) dispatchBatch(line []byte) {
+	var reqs []*JSONRPCRequest
+	if err := json.Unmarshal(line, &reqs); err != nil || len(reqs) == 0 {
+		c.writeMessage(invalidBatchResponse())
+		return
+	}
+
+	if bh, ok := c.handler.(BatchHandler); ok {
+		c.writeBatchResponses(bh.HandleBatch(reqs))
+		return
+	}
+
+	responses := make([]*JSONRPCResponse, 0, len(reqs))
+	for _, req := range reqs {
+		result, jerr := c.handler.Handle(context.Background(), req.Method, req.Params)
+		if isNotification(req) {
+			continue
+		}
+		responses = append(responses, &JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: result, Error: jerr})
+	}
+	c.writeBatchResponses(responses)
+}
+
+// writeBatchResponses writes a batch's aggregated responses, unless the
+// batch consisted entirely of notifications. Per the JSON-RPC 2.0 spec, a
+// server "MUST NOT return an empty Array" for an all-notifications batch -
+// it should write nothing at all.
+func (c *Conn) writeBatchResponses(responses []*JSONRPCResponse) {
+	if len(responses) == 0 {
+		return
+	}
+	c.writeMessage(responses)
+}
+
+// deliver routes an inbound response to the pending call waiting on it.
+func (c *Conn) deliver(resp *JSONRPCResponse) {
+	key := idKey(resp.ID)
+
+	c.mu.Lock()
+	ch, ok := c.pending[key]
+	if ok {
+		delete(c.pending, key)
+	}
+	c.mu.Unlock()
+
+	if ok {
+		ch <- resp
+	}
+}
+
+// handleInbound dispatches an inbound request or notification to the
+// handler in its own goroutine, tracking its cancel func so a later
+// $/cancelRequest can stop it.
+func (c *Conn) handleInbound(req *JSONRPCRequest) {
+	if req.Method == "$/cancelRequest" {
+		c.handleCancel(req)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	key := idKey(req.ID)
+	isCall := req.ID != nil
+
+	if isCall {
+		c.mu.Lock()
+		c.handling[key] = cancel
+		c.mu.Unlock()
+	}
+
+	go func() {
+		defer cancel()
+		result, jerr := c.handler.Handle(ctx, req.Method, req.Params)
+		if !isCall {
+			return
+		}
+
+		c.mu.Lock()
+		delete(c.handling, key)
+		c.mu.Unlock()
+
+		c.writeMessage(&JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: result, Error: jerr})
+	}()
+}
+
+// handleCancel looks up the in-flight request named in a $/cancelRequest
+// notification and cancels its context.
+func (c *Conn) handleCancel(req *JSONRPCRequest) {
+	var params cancelParams
+	if raw, err := json.Marshal(req.Params); err == nil {
+		json.Unmarshal(raw, &params)
+	}
+
+	key := idKey(params.ID)
+	c.mu.Lock()
+	cancel, ok := c.handling[key]
+	c.mu.Unlock()
+
+	if ok {
+		cancel()
+	}
+}
+
+// Notify sends a one-way notification (no id, no response expected).
+func (c *Conn) Notify(method string, params interface{}) error {
+	return c.writeMessage(&JSONRPCRequest{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// Call sends a request and blocks until a matching response arrives, ctx is
+// canceled, or the connection closes. If result is non-nil, the response's
+// Result is unmarshaled into it.
+func (c *Conn) Call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	id := atomic.AddInt64(&c.nextID, 1)
+	key := idKey(float64(id))
+
+	ch := make(chan *JSONRPCResponse, 1)
+	c.mu.Lock()
+	c.pending[key] = ch
+	c.mu.Unlock()
+
+	if err := c.writeMessage(&JSONRPCRequest{JSONRPC: "2.0", ID: id, Method: method, Params: params}); err != nil {
+		c.mu.Lock()
+		delete(c.pending, key)
+		c.mu.Unlock()
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return fmt.Errorf("%s: %s", method, resp.Error.Message)
+		}
+		if result != nil && resp.Result != nil {
+			raw, err := json.Marshal(resp.Result)
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal(raw, result)
+		}
+		return nil
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.pending, key)
+		c.mu.Unlock()
+		return ctx.Err()
+	case <-c.closed:
+		return fmt.Errorf("%s: connection closed", method)
+	}
+}
+
+// CancelRequest sends a $/cancelRequest notification for id, asking the
+// peer to cancel the corresponding in-flight call.
+func (c *Conn) CancelRequest(id interface{}) error {
+	return c.Notify("$/cancelRequest", cancelParams{ID: id})
+}
+
+// writeMessage serializes v as a single line. Writes are serialized against
+// each other so messages from concurrent handler goroutines don't interleave.
+func (c *Conn) writeMessage(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err = fmt.Fprintln(c.w, string(data))
+	return err
+}
+
+// closeWithError drains all pending calls with err and marks the
+// connection closed.
+func (c *Conn) closeWithError(err error) {
+	c.once.Do(func() {
+		c.mu.Lock()
+		pending := c.pending
+		c.pending = make(map[string]chan *JSONRPCResponse)
+		c.mu.Unlock()
+
+		for _, ch := range pending {
+			ch <- &JSONRPCResponse{
+				JSONRPC: "2.0",
+				Error: &JSONRPCError{
+					Code:    -32603,
+					Message: "Internal error",
+					Data:    fmt.Sprintf("connection closed: %v", err),
+				},
+			}
+		}
+		close(c.closed)
+	})
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+)
+
+type noopHandler struct{}
+
+func (noopHandler) Handle(_ context.Context, _ string, _ interface{}) (interface{}, *JSONRPCError) {
+	return nil, nil
+}
+
+func TestConn_CallReceivesMatchingResponse(t *testing.T) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	conn := NewConn(inR, outW, noopHandler{})
+	go conn.Run()
+
+	go func() {
+		scanner := bufio.NewScanner(outR)
+		for scanner.Scan() {
+			var req JSONRPCRequest
+			if err := json.Unmarshal(scanner.Bytes(), &req); err != nil || req.Method == "" {
+				continue
+			}
+			data, _ := json.Marshal(JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: "pong"})
+			inW.Write(append(data, '\n'))
+			return
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	var result string
+	if err := conn.Call(ctx, "ping", nil, &result); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if result != "pong" {
+		t.Errorf("Expected result 'pong', got %q", result)
+	}
+}
+
+func TestConn_AllNotificationBatchWritesNothing(t *testing.T) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	conn := NewConn(inR, outW, noopHandler{})
+	go conn.Run()
+
+	lines := make(chan []byte, 1)
+	go func() {
+		scanner := bufio.NewScanner(outR)
+		for scanner.Scan() {
+			lines <- append([]byte(nil), scanner.Bytes()...)
+		}
+	}()
+
+	batch, _ := json.Marshal([]JSONRPCRequest{
+		{JSONRPC: "2.0", Method: "log"},
+		{JSONRPC: "2.0", Method: "telemetry"},
+	})
+	inW.Write(append(batch, '\n'))
+
+	select {
+	case line := <-lines:
+		t.Fatalf("Expected no output for an all-notification batch, got %q", line)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+type slowBatchHandler struct {
+	started  chan struct{}
+	release  chan struct{}
+	canceled chan struct{}
+}
+
+func (h *slowBatchHandler) Handle(ctx context.Context, _ string, _ interface{}) (interface{}, *JSONRPCError) {
+	<-ctx.Done()
+	close(h.canceled)
+	return nil, &JSONRPCError{Code: -32000, Message: "canceled"}
+}
+
+func (h *slowBatchHandler) HandleBatch(reqs []*JSONRPCRequest) []*JSONRPCResponse {
+	close(h.started)
+	<-h.release
+	return []*JSONRPCResponse{{JSONRPC: "2.0", ID: reqs[0].ID, Result: "done"}}
+}
+
+func TestConn_BatchDispatchDoesNotStallReadLoop(t *testing.T) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	h := &slowBatchHandler{started: make(chan struct{}), release: make(chan struct{}), canceled: make(chan struct{})}
+	conn := NewConn(inR, outW, h)
+	go conn.Run()
+	go io.Copy(io.Discard, outR)
+
+	batch, _ := json.Marshal([]JSONRPCRequest{{JSONRPC: "2.0", ID: 1, Method: "slow"}})
+	inW.Write(append(batch, '\n'))
+
+	select {
+	case <-h.started:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected batch handler to start")
+	}
+
+	// While the batch is still in flight, a request and its cancellation
+	// must still be processed by the same read loop.
+	req, _ := json.Marshal(JSONRPCRequest{JSONRPC: "2.0", ID: 2, Method: "other"})
+	inW.Write(append(req, '\n'))
+	time.Sleep(20 * time.Millisecond)
+
+	cancelReq, _ := json.Marshal(JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "$/cancelRequest",
+		Params:  map[string]interface{}{"id": 2.0},
+	})
+	inW.Write(append(cancelReq, '\n'))
+
+	select {
+	case <-h.canceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the request behind the in-flight batch to be canceled, read loop appears stalled")
+	}
+
+	close(h.release)
+}
+
+type blockingHandler struct {
+	canceled chan struct{}
+}
+
+func (h *blockingHandler) Handle(ctx context.Context, _ string, _ interface{}) (interface{}, *JSONRPCError) {
+	<-ctx.Done()
+	close(h.canceled)
+	return nil, &JSONRPCError{Code: -32000, Message: "canceled"}
+}
+
+func TestConn_CancelRequestCancelsInFlightHandler(t *testing.T) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	h := &blockingHandler{canceled: make(chan struct{})}
+	conn := NewConn(inR, outW, h)
+	go conn.Run()
+	go io.Copy(io.Discard, outR) // drain the eventual (canceled) response
+
+	req, _ := json.Marshal(JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "slow"})
+	inW.Write(append(req, '\n'))
+
+	// Give the handler a moment to register before canceling it.
+	time.Sleep(20 * time.Millisecond)
+
+	cancelReq, _ := json.Marshal(JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  "$/cancelRequest",
+		Params:  map[string]interface{}{"id": 1.0},
+	})
+	inW.Write(append(cancelReq, '\n'))
+
+	select {
+	case <-h.canceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected handler context to be canceled")
+	}
+}
@@ -1,9 +1,6 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
-	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -41,131 +38,103 @@ type JSONRPCResponse struct {
 type Client struct {
 	lambdaURL string
 	client    *http.Client
+	transport Transport
+
+	// streamClient is used for Subscribe's long-lived streaming requests. It
+	// shares client's underlying Transport (and thus its connection pool)
+	// but has no overall Timeout, since a healthy stream is expected to stay
+	// open indefinitely; lifecycle is governed entirely by ctx cancellation.
+	streamClient *http.Client
+
+	// notify sends a notification back over stdio, e.g. for $/progress
+	// updates on slow calls. Wired up by Run via Conn.Notify; nil (a no-op)
+	// when the client is used directly, as in tests.
+	notify func(method string, params interface{}) error
+
+	// tracer, metrics, and logger are the observability hooks described in
+	// observability.go. All three are nil by default, making instrumentation
+	// a no-op until a caller opts in (metrics via OPENCONTEXT_METRICS_ADDR,
+	// tracer/logger by assigning them directly).
+	tracer  Tracer
+	metrics *Metrics
+	logger  Logger
+
+	// batchUnsupported remembers that the Lambda URL has already rejected a
+	// single-POST batch (see postBatch/HandleBatchHTTP in batch.go), so
+	// later batches skip straight to fan-out instead of re-trying a request
+	// shape the server has already told us it doesn't understand. 0/1 used
+	// in place of a bool for atomic access from concurrent batch calls.
+	batchUnsupported int32
 }
 
-// NewClient creates a new Client instance
-func NewClient(lambdaURL string, timeout time.Duration) *Client {
-	return &Client{
-		lambdaURL: strings.TrimRight(lambdaURL, "/"),
-		client: &http.Client{
-			Timeout: timeout,
-		},
+// newTransport builds an http.Transport tuned for reuse across many
+// short-lived JSON-RPC calls to the same Lambda URL: idle connections are
+// kept warm per host, and HTTP/2 is attempted whenever the server supports it.
+func newTransport() *http.Transport {
+	return &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     90 * time.Second,
+		DisableKeepAlives:   false,
+		ForceAttemptHTTP2:   true,
 	}
 }
 
-// HandleRequest processes a single JSON-RPC request
-func (c *Client) HandleRequest(req *JSONRPCRequest) *JSONRPCResponse {
-	// Marshal request
-	reqJSON, err := json.Marshal(req)
-	if err != nil {
-		return &JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      req.ID,
-			Error: &JSONRPCError{
-				Code:    -32700, // Parse error
-				Message: "Parse error",
-				Data:    err.Error(),
-			},
-		}
-	}
+// ClientOption configures optional NewClient behavior that most callers
+// don't need to override.
+type ClientOption func(*clientConfig)
 
-	// Create HTTP request
-	httpReq, err := http.NewRequest("POST", c.lambdaURL, bytes.NewBuffer(reqJSON))
-	if err != nil {
-		return &JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      req.ID,
-			Error: &JSONRPCError{
-				Code:    -32603, // Internal error
-				Message: "Internal error",
-				Data:    err.Error(),
-			},
-		}
+// clientConfig collects the settings ClientOptions may override, applied
+// on top of NewClient's defaults.
+type clientConfig struct {
+	wsBufferLimit int
+}
+
+// WithWebSocketBufferLimit overrides how many calls a ws:// or wss://
+// transport queues while reconnecting before RoundTrip rejects new calls
+// with ErrConnectionLost. The default is wsMaxBufferedCalls.
+func WithWebSocketBufferLimit(n int) ClientOption {
+	return func(cfg *clientConfig) { cfg.wsBufferLimit = n }
+}
+
+// NewClient creates a new Client instance. The scheme of lambdaURL selects
+// the Transport used to reach it: plain https:// (and bare http:// in
+// tests) use the original Lambda HTTPS transport, unix:// dials a local
+// domain socket, and ws:// / wss:// multiplex calls over a WebSocket (see
+// transport.go).
+func NewClient(lambdaURL string, timeout time.Duration, opts ...ClientOption) *Client {
+	var cfg clientConfig
+	for _, opt := range opts {
+		opt(&cfg)
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
-
-	// Send request
-	resp, err := c.client.Do(httpReq)
-	if err != nil {
-		return &JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      req.ID,
-			Error: &JSONRPCError{
-				Code:    -32603, // Internal error (HTTP error in Python client)
-				Message: "HTTP error",
-				Data:    err.Error(),
-			},
-		}
+
+	trimmed := strings.TrimRight(lambdaURL, "/")
+	sharedTransport := newTransport()
+	httpClient := &http.Client{
+		Timeout:   timeout,
+		Transport: sharedTransport,
 	}
-	defer resp.Body.Close()
-
-	// Check status code
-	if resp.StatusCode >= 400 {
-		return &JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      req.ID,
-			Error: &JSONRPCError{
-				Code:    -32603, // Internal error
-				Message: "HTTP error",
-				Data:    fmt.Sprintf("Status: %d", resp.StatusCode),
-			},
-		}
+	streamClient := &http.Client{
+		Transport: sharedTransport,
 	}
 
-	// Parse response
-	var jsonResp JSONRPCResponse
-	if err := json.NewDecoder(resp.Body).Decode(&jsonResp); err != nil {
-		return &JSONRPCResponse{
-			JSONRPC: "2.0",
-			ID:      req.ID,
-			Error: &JSONRPCError{
-				Code:    -32603, // Internal error
-				Message: "Invalid JSON response from server",
-				Data:    fmt.Sprintf("Failed to parse response: %s", err.Error()),
-			},
-		}
+	return &Client{
+		lambdaURL:    trimmed,
+		client:       httpClient,
+		streamClient: streamClient,
+		transport:    newTransportFor(trimmed, httpClient, cfg.wsBufferLimit),
 	}
-
-	return &jsonResp
 }
 
-// Run starts the client loop
+// Run starts the client's stdio dispatcher. It blocks until stdin closes,
+// processing each inbound line as a JSON-RPC call, notification, or batch
+// (see Conn), and forwarding calls to the Lambda URL.
 func (c *Client) Run() error {
-	scanner := bufio.NewScanner(os.Stdin)
-	
-	// Set a large buffer size for long lines if needed, but default is usually fine (64k)
-	// We'll stick to default for now as it matches Python's line reading
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
+	conn := NewConn(os.Stdin, os.Stdout, clientHandler{client: c})
+	c.notify = conn.Notify
 
-		var req JSONRPCRequest
-		if err := json.Unmarshal([]byte(line), &req); err != nil {
-			resp := &JSONRPCResponse{
-				JSONRPC: "2.0",
-				ID:      nil,
-				Error: &JSONRPCError{
-					Code:    -32700, // Parse error
-					Message: "Parse error",
-					Data:    err.Error(),
-				},
-			}
-			respJSON, _ := json.Marshal(resp)
-			fmt.Println(string(respJSON))
-			os.Stdout.Sync() // Ensure immediate flush for Claude Desktop
-			continue
-		}
-
-		resp := c.HandleRequest(&req)
-		respJSON, _ := json.Marshal(resp)
-		fmt.Println(string(respJSON))
-		os.Stdout.Sync() // Ensure immediate flush for Claude Desktop
-	}
-
-	if err := scanner.Err(); err != nil && err != io.EOF {
+	if err := conn.Run(); err != nil && err != io.EOF {
 		return err
 	}
 
@@ -203,7 +172,27 @@ func main() {
 		}
 	}
 
-	client := NewClient(lambdaURL, time.Duration(timeout)*time.Second)
+	var clientOpts []ClientOption
+	if bufStr := os.Getenv("OPENCONTEXT_WS_BUFFER_LIMIT"); bufStr != "" {
+		if n, err := strconv.Atoi(bufStr); err == nil && n > 0 {
+			clientOpts = append(clientOpts, WithWebSocketBufferLimit(n))
+		} else {
+			fmt.Fprintf(os.Stderr, "Error: Invalid OPENCONTEXT_WS_BUFFER_LIMIT value '%s'. Must be a positive integer.\n", bufStr)
+			os.Exit(1)
+		}
+	}
+
+	client := NewClient(lambdaURL, time.Duration(timeout)*time.Second, clientOpts...)
+
+	if addr := os.Getenv("OPENCONTEXT_METRICS_ADDR"); addr != "" {
+		client.metrics = NewMetrics()
+		go func() {
+			if err := StartMetricsServer(addr, client.metrics); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: metrics server: %v\n", err)
+			}
+		}()
+	}
+
 	if err := client.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Span is a single jsonrpc.<method> trace span: a name, a W3C trace/span id
+// pair, start/end times, and attributes. It mirrors the shape of an
+// OpenTelemetry span closely enough that a Tracer can translate it into a
+// real exported span, without this source tree depending on the
+// OpenTelemetry SDK directly (there's no module/dependency management here
+// to vendor it).
+type Span struct {
+	Name       string
+	TraceID    string
+	SpanID     string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{}
+}
+
+// SetAttr records a single span attribute.
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]interface{}, 4)
+	}
+	s.Attributes[key] = value
+}
+
+// Traceparent renders the span's context as a W3C traceparent header value
+// (https://www.w3.org/TR/trace-context/), for injection into the outbound
+// HTTP request so the Lambda side can continue the same trace.
+func (s *Span) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", s.TraceID, s.SpanID)
+}
+
+// Tracer receives completed spans, mirroring the Logger/Metrics
+// optionality: a nil Tracer (the default) disables tracing with no
+// allocation beyond the span itself. Wire in an adapter over an
+// OpenTelemetry SpanExporter to ship spans to a real tracing backend.
+type Tracer interface {
+	RecordSpan(span *Span)
+}
+
+// startSpan begins a new span named jsonrpc.<method>, generating a random
+// W3C-compatible trace/span id pair.
+func startSpan(method string) (*Span, error) {
+	traceID := make([]byte, 16)
+	if _, err := rand.Read(traceID); err != nil {
+		return nil, err
+	}
+	spanID := make([]byte, 8)
+	if _, err := rand.Read(spanID); err != nil {
+		return nil, err
+	}
+	return &Span{
+		Name:    "jsonrpc." + method,
+		TraceID: hex.EncodeToString(traceID),
+		SpanID:  hex.EncodeToString(spanID),
+		Start:   time.Now(),
+	}, nil
+}
+
+// Logger is the structured logging interface instrumentation writes
+// request/response pairs through at debug level, so callers can plug in
+// slog, zap, or anything else. A nil Logger (the default) disables
+// logging entirely with no allocation.
+type Logger interface {
+	Debug(msg string, fields map[string]interface{})
+}
+
+// maxLoggedFieldBytes truncates the logged result value past this length
+// so large payloads don't blow up log volume. Params are logged whole
+// (after redaction), not truncated.
+const maxLoggedFieldBytes = 2048
+
+// redactedParamKeys are params field names whose values are replaced with
+// "[REDACTED]" before logging.
+var redactedParamKeys = map[string]bool{
+	"password":      true,
+	"token":         true,
+	"secret":        true,
+	"api_key":       true,
+	"apikey":        true,
+	"authorization": true,
+}
+
+// JSONLogger is a Logger that writes newline-delimited JSON entries to w.
+type JSONLogger struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+// NewJSONLogger creates a JSONLogger writing to w.
+func NewJSONLogger(w io.Writer) *JSONLogger {
+	return &JSONLogger{w: w}
+}
+
+func (l *JSONLogger) Debug(msg string, fields map[string]interface{}) {
+	entry := make(map[string]interface{}, len(fields)+2)
+	for k, v := range fields {
+		entry[k] = v
+	}
+	entry["level"] = "debug"
+	entry["msg"] = msg
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(append(data, '\n'))
+}
+
+// redactParams returns params with any key in redactedParamKeys replaced
+// by "[REDACTED]", so request logs never carry secrets. Non-map params
+// (or nil) are returned unchanged.
+func redactParams(params interface{}) interface{} {
+	m, ok := params.(map[string]interface{})
+	if !ok {
+		return params
+	}
+
+	redacted := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if redactedParamKeys[strings.ToLower(k)] {
+			redacted[k] = "[REDACTED]"
+		} else {
+			redacted[k] = v
+		}
+	}
+	return redacted
+}
+
+// truncateField shortens s to maxLoggedFieldBytes for logging.
+func truncateField(s string) string {
+	if len(s) <= maxLoggedFieldBytes {
+		return s
+	}
+	return s[:maxLoggedFieldBytes] + "...(truncated)"
+}
+
+// recordObservability ends span (if tracing is enabled), records metrics,
+// and emits a debug log entry for a completed call. All three are no-ops
+// when the corresponding c.tracer / c.metrics / c.logger is unset.
+func (c *Client) recordObservability(req *JSONRPCRequest, resp *JSONRPCResponse, span *Span, elapsed time.Duration) {
+	code := 0
+	if resp.Error != nil {
+		code = resp.Error.Code
+	}
+	resultJSON, _ := json.Marshal(resp.Result)
+	responseSize := len(resultJSON)
+
+	if c.tracer != nil && span != nil {
+		span.End = span.Start.Add(elapsed)
+		span.SetAttr("method", req.Method)
+		span.SetAttr("id", fmt.Sprintf("%v", req.ID))
+		span.SetAttr("response_size_bytes", responseSize)
+		span.SetAttr("error_code", code)
+		span.SetAttr("lambda_latency_ms", elapsed.Milliseconds())
+		c.tracer.RecordSpan(span)
+	}
+
+	c.metrics.record(req.Method, code, elapsed)
+
+	if c.logger == nil {
+		return
+	}
+
+	c.logger.Debug("jsonrpc call", map[string]interface{}{
+		"method":        req.Method,
+		"id":            fmt.Sprintf("%v", req.ID),
+		"params":        redactParams(req.Params),
+		"result":        truncateField(string(resultJSON)),
+		"response_size": responseSize,
+		"error_code":    code,
+		"elapsed_ms":    elapsed.Milliseconds(),
+	})
+}
+
+// durationHistogramBuckets are the upper bounds (seconds) of the latency
+// buckets tracked per method, matching Prometheus's "le" bucket convention.
+var durationHistogramBuckets = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10}
+
+// durationHistogram is a minimal cumulative latency histogram, rendered in
+// Prometheus's histogram exposition format by Metrics.ServeHTTP.
+type durationHistogram struct {
+	buckets []int64 // counts of observations <= durationHistogramBuckets[i]
+	sum     float64
+	count   int64
+}
+
+func newDurationHistogram() *durationHistogram {
+	return &durationHistogram{buckets: make([]int64, len(durationHistogramBuckets))}
+}
+
+func (h *durationHistogram) observe(seconds float64) {
+	h.sum += seconds
+	h.count++
+	for i, le := range durationHistogramBuckets {
+		if seconds <= le {
+			h.buckets[i]++
+		}
+	}
+}
+
+// Metrics holds Prometheus-style counters and histograms for JSON-RPC
+// calls (opencontext_requests_total, opencontext_request_duration_seconds),
+// rendered in the Prometheus text exposition format by ServeHTTP. A nil
+// *Metrics makes record a no-op, so nothing is counted unless a caller opts
+// in (see OPENCONTEXT_METRICS_ADDR in main). Leaving metrics, tracer, and
+// logger all unset does not make a call free: HandleRequest still starts a
+// span and recordObservability still encodes the result to measure it.
+type Metrics struct {
+	mu        sync.Mutex
+	counters  map[string]int64 // "method|code" -> count
+	durations map[string]*durationHistogram
+}
+
+// NewMetrics creates an empty Metrics collector.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		counters:  make(map[string]int64),
+		durations: make(map[string]*durationHistogram),
+	}
+}
+
+func (m *Metrics) record(method string, code int, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.counters[fmt.Sprintf("%s|%d", method, code)]++
+
+	hist, ok := m.durations[method]
+	if !ok {
+		hist = newDurationHistogram()
+		m.durations[method] = hist
+	}
+	hist.observe(elapsed.Seconds())
+}
+
+// ServeHTTP renders the collected metrics in Prometheus text exposition
+// format, so a *Metrics can be mounted directly as an http.Handler.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# HELP opencontext_requests_total Total JSON-RPC requests by method and response code.")
+	fmt.Fprintln(w, "# TYPE opencontext_requests_total counter")
+	for key, count := range m.counters {
+		method, code, _ := strings.Cut(key, "|")
+		fmt.Fprintf(w, "opencontext_requests_total{method=%q,code=%q} %d\n", method, code, count)
+	}
+
+	fmt.Fprintln(w, "# HELP opencontext_request_duration_seconds JSON-RPC request latency by method.")
+	fmt.Fprintln(w, "# TYPE opencontext_request_duration_seconds histogram")
+	for method, hist := range m.durations {
+		for i, le := range durationHistogramBuckets {
+			fmt.Fprintf(w, "opencontext_request_duration_seconds_bucket{method=%q,le=%q} %d\n", method, fmt.Sprintf("%g", le), hist.buckets[i])
+		}
+		fmt.Fprintf(w, "opencontext_request_duration_seconds_bucket{method=%q,le=\"+Inf\"} %d\n", method, hist.count)
+		fmt.Fprintf(w, "opencontext_request_duration_seconds_sum{method=%q} %g\n", method, hist.sum)
+		fmt.Fprintf(w, "opencontext_request_duration_seconds_count{method=%q} %d\n", method, hist.count)
+	}
+}
+
+// StartMetricsServer starts an HTTP listener serving m at /metrics on addr.
+// It blocks until the listener errors, so callers typically run it in its
+// own goroutine (see main).
+func StartMetricsServer(addr string, m *Metrics) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	return http.ListenAndServe(addr, mux)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStartSpan_TraceparentMatchesW3CFormat(t *testing.T) {
+	span, err := startSpan("context.get")
+	if err != nil {
+		t.Fatalf("startSpan failed: %v", err)
+	}
+	if span.Name != "jsonrpc.context.get" {
+		t.Errorf("Expected span name %q, got %q", "jsonrpc.context.get", span.Name)
+	}
+
+	tp := span.Traceparent()
+	parts := strings.Split(tp, "-")
+	if len(parts) != 4 {
+		t.Fatalf("Expected 4 dash-separated parts, got %d (%q)", len(parts), tp)
+	}
+	if parts[0] != "00" {
+		t.Errorf("Expected version %q, got %q", "00", parts[0])
+	}
+	if len(parts[1]) != 32 {
+		t.Errorf("Expected 32-char trace-id, got %d chars", len(parts[1]))
+	}
+	if len(parts[2]) != 16 {
+		t.Errorf("Expected 16-char span-id, got %d chars", len(parts[2]))
+	}
+}
+
+type recordingTracer struct {
+	spans []*Span
+}
+
+func (rt *recordingTracer) RecordSpan(span *Span) {
+	rt.spans = append(rt.spans, span)
+}
+
+func TestHandleRequest_RecordsSpanWithAttributes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(JSONRPCResponse{JSONRPC: "2.0", ID: 1, Result: "pong"})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, 2*time.Second)
+	tracer := &recordingTracer{}
+	client.tracer = tracer
+
+	client.HandleRequest(&JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "ping"})
+
+	if len(tracer.spans) != 1 {
+		t.Fatalf("Expected 1 recorded span, got %d", len(tracer.spans))
+	}
+	span := tracer.spans[0]
+	if span.Name != "jsonrpc.ping" {
+		t.Errorf("Expected span name %q, got %q", "jsonrpc.ping", span.Name)
+	}
+	if span.Attributes["method"] != "ping" {
+		t.Errorf("Expected method attribute 'ping', got %v", span.Attributes["method"])
+	}
+	if span.Attributes["error_code"] != 0 {
+		t.Errorf("Expected error_code 0, got %v", span.Attributes["error_code"])
+	}
+	if size, ok := span.Attributes["response_size_bytes"].(int); !ok || size == 0 {
+		t.Errorf("Expected a non-zero response_size_bytes attribute, got %v", span.Attributes["response_size_bytes"])
+	}
+	if span.End.Before(span.Start) {
+		t.Errorf("Expected span End to be at or after Start")
+	}
+}
+
+func TestRedactParams_RedactsKnownKeys(t *testing.T) {
+	got := redactParams(map[string]interface{}{
+		"username": "alice",
+		"password": "hunter2",
+		"Token":    "abc123",
+	})
+
+	m, ok := got.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected map[string]interface{}, got %T", got)
+	}
+	if m["username"] != "alice" {
+		t.Errorf("Expected username to pass through unredacted, got %v", m["username"])
+	}
+	if m["password"] != "[REDACTED]" {
+		t.Errorf("Expected password to be redacted, got %v", m["password"])
+	}
+	if m["Token"] != "[REDACTED]" {
+		t.Errorf("Expected Token to be redacted case-insensitively, got %v", m["Token"])
+	}
+}
+
+func TestMetrics_RecordAndServeHTTP(t *testing.T) {
+	m := NewMetrics()
+	m.record("context.get", 0, 15*time.Millisecond)
+	m.record("context.get", -32001, 3*time.Second)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	m.ServeHTTP(rec, req)
+
+	body := rec.Body.String()
+	if !strings.Contains(body, `opencontext_requests_total{method="context.get",code="0"} 1`) {
+		t.Errorf("Expected a counter line for code 0, got body:\n%s", body)
+	}
+	if !strings.Contains(body, `opencontext_requests_total{method="context.get",code="-32001"} 1`) {
+		t.Errorf("Expected a counter line for code -32001, got body:\n%s", body)
+	}
+	if !strings.Contains(body, `opencontext_request_duration_seconds_count{method="context.get"} 2`) {
+		t.Errorf("Expected a duration count of 2, got body:\n%s", body)
+	}
+}
+
+func TestMetrics_RecordOnNilReceiverIsNoop(t *testing.T) {
+	var m *Metrics
+	m.record("context.get", 0, time.Millisecond) // must not panic
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// subscribeBackoff bounds the exponential backoff delays between reconnect
+// attempts for a Subscribe stream.
+var subscribeBackoff = []time.Duration{
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2 * time.Second,
+	5 * time.Second,
+}
+
+// subscribeFrame is a single NDJSON/SSE frame from the /subscribe stream: a
+// JSON-RPC response plus an opaque resume token the client echoes back on
+// reconnect so the server can replay anything missed.
+type subscribeFrame struct {
+	JSONRPCResponse
+	Resume string `json:"resume,omitempty"`
+}
+
+// Subscribe opens a long-lived streaming request to the Lambda's /subscribe
+// endpoint for method/params and routes each decoded JSONRPCResponse frame
+// to ch. Dropped connections are retried with exponential backoff, echoing
+// back the last resume token so the server can replay what was missed.
+// Subscribe blocks until ctx is canceled, which aborts any in-flight
+// request; ch is closed before Subscribe returns.
+func (c *Client) Subscribe(ctx context.Context, method string, params interface{}, ch chan<- *JSONRPCResponse) error {
+	defer close(ch)
+
+	var resumeToken string
+	attempt := 0
+
+	for {
+		err := c.subscribeOnce(ctx, method, params, ch, &resumeToken)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil {
+			// The server closed the stream cleanly; reconnect immediately.
+			attempt = 0
+			continue
+		}
+
+		delay := subscribeBackoff[attempt]
+		if attempt < len(subscribeBackoff)-1 {
+			attempt++
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// subscribeOnce opens a single streaming request and feeds decoded frames to
+// ch until the stream ends, the request fails, or ctx is canceled.
+// *resumeToken is updated from each frame so a subsequent reconnect can
+// resume where this attempt left off.
+func (c *Client) subscribeOnce(ctx context.Context, method string, params interface{}, ch chan<- *JSONRPCResponse, resumeToken *string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+		"params":  params,
+		"resume":  *resumeToken,
+	})
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.lambdaURL+"/subscribe", strings.NewReader(string(body)))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/x-ndjson")
+
+	streamClient := c.streamClient
+	if streamClient == nil {
+		// Clients built directly (as in tests) without NewClient fall back to
+		// the default transport rather than panicking.
+		streamClient = http.DefaultClient
+	}
+
+	resp, err := streamClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("subscribe: status %d", resp.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		line = strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if line == "" {
+			continue
+		}
+
+		var frame subscribeFrame
+		if err := json.Unmarshal([]byte(line), &frame); err != nil {
+			continue
+		}
+		if frame.Resume != "" {
+			*resumeToken = frame.Resume
+		}
+
+		select {
+		case ch <- &frame.JSONRPCResponse:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSubscribe_RoutesFramesAndClosesOnCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("Expected a flushable response writer")
+		}
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","id":%d,"result":"tick"}`+"\n", i)
+			flusher.Flush()
+		}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, 5*time.Second)
+	ch := make(chan *JSONRPCResponse, 3)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() { done <- client.Subscribe(ctx, "watch", nil, ch) }()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case resp := <-ch:
+			if resp.Result != "tick" {
+				t.Errorf("Expected result 'tick', got %v", resp.Result)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("Timed out waiting for subscription frame")
+		}
+	}
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Timed out waiting for Subscribe to return")
+	}
+
+	if _, ok := <-ch; ok {
+		t.Error("Expected ch to be closed after Subscribe returns")
+	}
+}
+
+func TestSubscribe_OutlivesBoundedRPCTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("Expected a flushable response writer")
+		}
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":1,"result":"tick"}`+"\n")
+		flusher.Flush()
+		time.Sleep(150 * time.Millisecond)
+		fmt.Fprintf(w, `{"jsonrpc":"2.0","id":2,"result":"tock"}`+"\n")
+		flusher.Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	// A bounded RPC timeout far shorter than the stream's lifetime must not
+	// tear down a healthy subscription.
+	client := NewClient(server.URL, 50*time.Millisecond)
+	ch := make(chan *JSONRPCResponse, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() { client.Subscribe(ctx, "watch", nil, ch) }()
+
+	for i, want := range []string{"tick", "tock"} {
+		select {
+		case resp := <-ch:
+			if resp.Result != want {
+				t.Errorf("frame %d: expected result %q, got %v", i, want, resp.Result)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Timed out waiting for frame %d; subscription likely killed by RPC timeout", i)
+		}
+	}
+}
+
+func TestNewClient_TunedTransport(t *testing.T) {
+	client := NewClient("http://example.com", 10*time.Second)
+
+	transport, ok := client.client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Expected *http.Transport, got %T", client.client.Transport)
+	}
+	if !transport.ForceAttemptHTTP2 {
+		t.Error("Expected ForceAttemptHTTP2 to be true")
+	}
+	if transport.MaxIdleConnsPerHost == 0 {
+		t.Error("Expected MaxIdleConnsPerHost to be tuned above the default")
+	}
+}
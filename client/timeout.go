@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// softDeadlineMargin is how far ahead of the hard timeout the soft
+	// deadline fires, giving the caller a chance to hear that a call is
+	// still running before it times out.
+	softDeadlineMargin = 500 * time.Millisecond
+
+	// gzipMinTimeout is the shortest client timeout for which gzip is
+	// negotiated on outbound requests. Below it, a response could be cut
+	// off mid-frame before the gzip decoder can finish, so compression is
+	// skipped in favor of a clean partial read.
+	gzipMinTimeout = 2 * time.Second
+)
+
+// HandleRequest processes a single JSON-RPC request against the Lambda URL,
+// enforcing a two-tier deadline derived from the client's configured
+// timeout, and records it for tracing, metrics, and logging (see
+// observability.go).
+func (c *Client) HandleRequest(req *JSONRPCRequest) *JSONRPCResponse {
+	start := time.Now()
+
+	span, _ := startSpan(req.Method)
+	ctx := context.Background()
+	if span != nil {
+		ctx = withTraceparent(ctx, span.Traceparent())
+	}
+
+	resp := c.handleWithDeadline(ctx, req)
+	c.recordObservability(req, resp, span, time.Since(start))
+
+	return resp
+}
+
+// handleWithDeadline is HandleRequest's original two-tier-deadline
+// behavior: if the soft deadline (timeout minus softDeadlineMargin)
+// passes before the Lambda responds, a $/progress notification is sent
+// over stdio so the caller knows the call is still in flight. If the hard
+// deadline passes, the request is canceled and a -32001 "Request timeout"
+// error is returned instead of the generic HTTP error. parentCtx carries
+// cross-cutting values (e.g. the traceparent) through to the transport.
+func (c *Client) handleWithDeadline(parentCtx context.Context, req *JSONRPCRequest) *JSONRPCResponse {
+	timeout := c.client.Timeout
+	if timeout <= 0 {
+		// No deadline means nothing can truncate a gzip stream mid-frame, so
+		// compression is always safe to negotiate here.
+		resp, err := c.transport.RoundTrip(withGzipPreference(parentCtx, true), req)
+		return transportResponse(req, resp, err)
+	}
+
+	ctx, cancel := context.WithTimeout(parentCtx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan struct{})
+	defer close(done)
+
+	if softDeadline := timeout - softDeadlineMargin; softDeadline > 0 && c.notify != nil {
+		timer := time.AfterFunc(softDeadline, func() {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			c.notify("$/progress", map[string]interface{}{
+				"method":  req.Method,
+				"elapsed": time.Since(start).String(),
+			})
+		})
+		defer timer.Stop()
+	}
+
+	useGzip := timeout >= gzipMinTimeout
+	resp, err := c.transport.RoundTrip(withGzipPreference(ctx, useGzip), req)
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error: &JSONRPCError{
+				Code:    -32001,
+				Message: "Request timeout",
+				Data:    fmt.Sprintf("method=%s elapsed=%s", req.Method, time.Since(start)),
+			},
+		}
+	}
+
+	return transportResponse(req, resp, err)
+}
+
+// transportResponse reconciles a Transport's result into a JSONRPCResponse:
+// resp is returned as-is when the transport completed the call, and a
+// transport-level err (one it couldn't embed in a response itself, such as
+// a dropped connection) is converted into the appropriate JSON-RPC error.
+func transportResponse(req *JSONRPCRequest, resp *JSONRPCResponse, err error) *JSONRPCResponse {
+	if err == nil {
+		return resp
+	}
+
+	code, message := -32603, "HTTP error"
+	if errors.Is(err, ErrConnectionLost) {
+		code, message = -32000, "Connection lost"
+	}
+
+	return &JSONRPCResponse{
+		JSONRPC: "2.0",
+		ID:      req.ID,
+		Error: &JSONRPCError{
+			Code:    code,
+			Message: message,
+			Data:    err.Error(),
+		},
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHandleRequest_SoftDeadlineNotification(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(400 * time.Millisecond)
+		json.NewEncoder(w).Encode(JSONRPCResponse{JSONRPC: "2.0", ID: 1, Result: "ok"})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, 600*time.Millisecond)
+
+	var mu sync.Mutex
+	var notifiedMethod string
+	client.notify = func(method string, params interface{}) error {
+		mu.Lock()
+		notifiedMethod = method
+		mu.Unlock()
+		return nil
+	}
+
+	resp := client.HandleRequest(&JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "slow"})
+
+	if resp.Error != nil {
+		t.Fatalf("Expected no error, got %v", resp.Error)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if notifiedMethod != "$/progress" {
+		t.Errorf("Expected a $/progress notification before the call finished, got %q", notifiedMethod)
+	}
+}
+
+func TestHandleRequest_HardDeadlineReturnsTimeoutError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, 100*time.Millisecond)
+	resp := client.HandleRequest(&JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "slow"})
+
+	if resp.Error == nil || resp.Error.Code != -32001 {
+		t.Fatalf("Expected error code -32001, got %v", resp.Error)
+	}
+}
+
+func TestHandleRequest_GzipResponseDecoded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Errorf("Expected Accept-Encoding: gzip, got %q", r.Header.Get("Accept-Encoding"))
+		}
+
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		json.NewEncoder(gz).Encode(JSONRPCResponse{JSONRPC: "2.0", ID: 1, Result: "zipped"})
+		gz.Close()
+
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(buf.Bytes())
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, 5*time.Second)
+	resp := client.HandleRequest(&JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "test"})
+
+	if resp.Error != nil {
+		t.Fatalf("Expected no error, got %v", resp.Error)
+	}
+	if resp.Result != "zipped" {
+		t.Errorf("Expected result 'zipped', got %v", resp.Result)
+	}
+}
+
+func TestHandleRequest_GzipAllowedWithNoTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if enc := r.Header.Get("Accept-Encoding"); enc != "gzip" {
+			t.Errorf("Expected gzip to be negotiated with no deadline, got %q", enc)
+		}
+		json.NewEncoder(w).Encode(JSONRPCResponse{JSONRPC: "2.0", ID: 1, Result: "ok"})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, 0)
+	resp := client.HandleRequest(&JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "test"})
+
+	if resp.Error != nil {
+		t.Fatalf("Expected no error, got %v", resp.Error)
+	}
+}
+
+func TestHandleRequest_GzipDisabledNearDeadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if enc := r.Header.Get("Accept-Encoding"); enc == "gzip" {
+			t.Errorf("Expected gzip not to be negotiated for a short timeout, got %q", enc)
+		}
+		json.NewEncoder(w).Encode(JSONRPCResponse{JSONRPC: "2.0", ID: 1, Result: "ok"})
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, 1*time.Second)
+	resp := client.HandleRequest(&JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "test"})
+
+	if resp.Error != nil {
+		t.Fatalf("Expected no error, got %v", resp.Error)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Transport is the pluggable backend a Client uses to exchange JSON-RPC
+// messages with the server. RoundTrip performs a single call; most
+// transport-level failures (bad status, malformed body) are reported as an
+// error JSONRPCResponse rather than a Go error, matching the original
+// HTTP client's behavior. A non-nil error signals the transport itself
+// couldn't complete the call (e.g. a dropped socket); HandleRequest maps
+// that to a JSON-RPC error before returning it to the caller. Stream opens
+// a bidirectional connection for transports that support server-initiated
+// traffic; transports that only do request/response return an error from
+// Stream.
+type Transport interface {
+	RoundTrip(ctx context.Context, req *JSONRPCRequest) (*JSONRPCResponse, error)
+	Stream(ctx context.Context) (io.ReadWriteCloser, error)
+}
+
+// ErrConnectionLost is returned (wrapped) by a Transport's RoundTrip when
+// the connection can't carry the call: the unix transport on a failed
+// dial, write, or read, and the WebSocket transport when its send queue is
+// full or it has been closed. A WebSocket call already in flight when the
+// socket drops gets a -32000 "Connection lost" response instead.
+var ErrConnectionLost = errors.New("connection lost")
+
+// newTransportFor selects a Transport based on rawURL's scheme: unix://
+// dials a local domain socket, ws:// and wss:// multiplex over a
+// WebSocket (buffering up to wsBufferLimit calls during a reconnect; <= 0
+// uses wsMaxBufferedCalls), and anything else (https://, or bare http://
+// as used by tests) goes over httpClient, matching the client's original
+// behavior.
+func newTransportFor(rawURL string, httpClient *http.Client, wsBufferLimit int) Transport {
+	switch {
+	case strings.HasPrefix(rawURL, "unix://"):
+		return newUnixTransport(strings.TrimPrefix(rawURL, "unix://"))
+	case strings.HasPrefix(rawURL, "ws://"), strings.HasPrefix(rawURL, "wss://"):
+		return newWebSocketTransport(rawURL, wsBufferLimit)
+	default:
+		return newHTTPTransport(rawURL, httpClient)
+	}
+}
+
+// gzipCtxKey is the context key HandleRequest uses to tell httpTransport
+// whether gzip should be negotiated for a given call.
+type gzipCtxKey struct{}
+
+// withGzipPreference attaches whether gzip should be negotiated for the
+// call carried by ctx.
+func withGzipPreference(ctx context.Context, enabled bool) context.Context {
+	return context.WithValue(ctx, gzipCtxKey{}, enabled)
+}
+
+func gzipPreference(ctx context.Context) bool {
+	enabled, _ := ctx.Value(gzipCtxKey{}).(bool)
+	return enabled
+}
+
+// traceparentCtxKey carries the current call's W3C traceparent header
+// value (see observability.go) through to httpTransport.
+type traceparentCtxKey struct{}
+
+func withTraceparent(ctx context.Context, traceparent string) context.Context {
+	return context.WithValue(ctx, traceparentCtxKey{}, traceparent)
+}
+
+func traceparentFromContext(ctx context.Context) string {
+	traceparent, _ := ctx.Value(traceparentCtxKey{}).(string)
+	return traceparent
+}
+
+// httpTransport is the original Transport: one JSON-RPC call per HTTPS
+// POST to the Lambda URL.
+type httpTransport struct {
+	url    string
+	client *http.Client
+}
+
+func newHTTPTransport(url string, client *http.Client) *httpTransport {
+	return &httpTransport{url: url, client: client}
+}
+
+func (t *httpTransport) RoundTrip(ctx context.Context, req *JSONRPCRequest) (*JSONRPCResponse, error) {
+	useGzip := gzipPreference(ctx)
+
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error: &JSONRPCError{
+				Code:    -32700, // Parse error
+				Message: "Parse error",
+				Data:    err.Error(),
+			},
+		}, nil
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", t.url, bytes.NewBuffer(reqJSON))
+	if err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error: &JSONRPCError{
+				Code:    -32603, // Internal error
+				Message: "Internal error",
+				Data:    err.Error(),
+			},
+		}, nil
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if useGzip {
+		httpReq.Header.Set("Accept-Encoding", "gzip")
+	} else {
+		// net/http negotiates gzip automatically unless a request already
+		// sets Accept-Encoding, so make the opt-out explicit.
+		httpReq.Header.Set("Accept-Encoding", "identity")
+	}
+	if traceparent := traceparentFromContext(ctx); traceparent != "" {
+		httpReq.Header.Set("traceparent", traceparent)
+	}
+
+	resp, err := t.client.Do(httpReq)
+	if err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error: &JSONRPCError{
+				Code:    -32603, // Internal error (HTTP error in Python client)
+				Message: "HTTP error",
+				Data:    err.Error(),
+			},
+		}, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error: &JSONRPCError{
+				Code:    -32603, // Internal error
+				Message: "HTTP error",
+				Data:    fmt.Sprintf("Status: %d", resp.StatusCode),
+			},
+		}, nil
+	}
+
+	respBody := io.Reader(resp.Body)
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return &JSONRPCResponse{
+				JSONRPC: "2.0",
+				ID:      req.ID,
+				Error: &JSONRPCError{
+					Code:    -32603, // Internal error
+					Message: "Invalid JSON response from server",
+					Data:    fmt.Sprintf("Failed to decompress response: %s", err.Error()),
+				},
+			}, nil
+		}
+		defer gz.Close()
+		respBody = gz
+	}
+
+	var jsonResp JSONRPCResponse
+	if err := json.NewDecoder(respBody).Decode(&jsonResp); err != nil {
+		return &JSONRPCResponse{
+			JSONRPC: "2.0",
+			ID:      req.ID,
+			Error: &JSONRPCError{
+				Code:    -32603, // Internal error
+				Message: "Invalid JSON response from server",
+				Data:    fmt.Sprintf("Failed to parse response: %s", err.Error()),
+			},
+		}, nil
+	}
+
+	return &jsonResp, nil
+}
+
+func (t *httpTransport) Stream(ctx context.Context) (io.ReadWriteCloser, error) {
+	return nil, fmt.Errorf("http transport does not support streaming; use Subscribe")
+}
+
+// unixTransport dials a fresh Unix domain socket connection per call, for
+// running the client against a locally-deployed OpenContext container.
+type unixTransport struct {
+	path string
+}
+
+func newUnixTransport(path string) *unixTransport {
+	return &unixTransport{path: path}
+}
+
+func (t *unixTransport) RoundTrip(ctx context.Context, req *JSONRPCRequest) (*JSONRPCResponse, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", t.path)
+	if err != nil {
+		return nil, fmt.Errorf("unix socket dial: %w: %v", ErrConnectionLost, err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConnectionLost, err)
+	}
+
+	var resp JSONRPCResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConnectionLost, err)
+	}
+
+	return &resp, nil
+}
+
+func (t *unixTransport) Stream(ctx context.Context) (io.ReadWriteCloser, error) {
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "unix", t.path)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewTransportFor_SelectsByScheme(t *testing.T) {
+	httpClient := &http.Client{}
+
+	tests := []struct {
+		url  string
+		want interface{}
+	}{
+		{"https://example.com/rpc", &httpTransport{}},
+		{"http://example.com/rpc", &httpTransport{}},
+		{"unix:///tmp/opencontext.sock", &unixTransport{}},
+		{"ws://example.com/rpc", &websocketTransport{}},
+		{"wss://example.com/rpc", &websocketTransport{}},
+	}
+
+	for _, tt := range tests {
+		got := newTransportFor(tt.url, httpClient, 0)
+		if ws, ok := got.(*websocketTransport); ok {
+			// Dials a background reconnect loop immediately; shut it down
+			// so it doesn't outlive the test.
+			defer ws.Close()
+		}
+
+		switch tt.want.(type) {
+		case *httpTransport:
+			if _, ok := got.(*httpTransport); !ok {
+				t.Errorf("%s: expected httpTransport, got %T", tt.url, got)
+			}
+		case *unixTransport:
+			if _, ok := got.(*unixTransport); !ok {
+				t.Errorf("%s: expected unixTransport, got %T", tt.url, got)
+			}
+		case *websocketTransport:
+			if _, ok := got.(*websocketTransport); !ok {
+				t.Errorf("%s: expected websocketTransport, got %T", tt.url, got)
+			}
+		}
+	}
+}
+
+func TestUnixTransport_RoundTrip(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "opencontext.sock")
+
+	listener, err := net.Listen("unix", sockPath)
+	if err != nil {
+		t.Fatalf("Failed to listen on unix socket: %v", err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var req JSONRPCRequest
+		if err := json.NewDecoder(conn).Decode(&req); err != nil {
+			return
+		}
+		json.NewEncoder(conn).Encode(JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: "pong"})
+	}()
+
+	client := NewClient("unix://"+sockPath, 2*time.Second)
+	resp := client.HandleRequest(&JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "ping"})
+
+	if resp.Error != nil {
+		t.Fatalf("Expected no error, got %v", resp.Error)
+	}
+	if resp.Result != "pong" {
+		t.Errorf("Expected result 'pong', got %v", resp.Result)
+	}
+}
+
+func TestUnixTransport_DialFailureReportsConnectionLost(t *testing.T) {
+	transport := newUnixTransport(filepath.Join(os.TempDir(), "does-not-exist.sock"))
+
+	_, err := transport.RoundTrip(context.Background(), &JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "ping"})
+	if err == nil {
+		t.Fatal("Expected an error for a missing socket")
+	}
+	if !errors.Is(err, ErrConnectionLost) {
+		t.Fatalf("Expected error to wrap ErrConnectionLost, got %v", err)
+	}
+
+	resp := transportResponse(&JSONRPCRequest{JSONRPC: "2.0", ID: 1}, nil, err)
+	if resp.Error == nil || resp.Error.Code != -32000 {
+		t.Errorf("Expected code -32000 \"Connection lost\", got %v", resp.Error)
+	}
+}
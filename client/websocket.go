@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// wsGUID is the fixed magic string used to compute Sec-WebSocket-Accept,
+// per RFC 6455 section 1.3.
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsMaxBufferedCalls is the default bound on how many calls can be queued
+// while a websocketTransport is reconnecting before RoundTrip rejects new
+// calls. Callers can override it via WithWebSocketBufferLimit.
+const wsMaxBufferedCalls = 64
+
+// wsReconnectBackoff bounds the delay between reconnect attempts.
+var wsReconnectBackoff = []time.Duration{
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2 * time.Second,
+	5 * time.Second,
+}
+
+// websocketTransport multiplexes many concurrent JSON-RPC calls over a
+// single WebSocket connection, correlating requests to responses by id. It
+// reconnects on drop with exponential backoff, buffering calls made during
+// a reconnect up to its buffer limit (wsMaxBufferedCalls by default), and
+// answers any call in flight when the socket dies with a -32000
+// "Connection lost" response (see failAllPending).
+type websocketTransport struct {
+	rawURL string
+	nextID int64
+
+	mu      sync.Mutex
+	pending map[string]chan *JSONRPCResponse
+
+	writeCh chan wsQueuedMsg
+	closed  chan struct{}
+	once    sync.Once
+}
+
+// wsQueuedMsg is an outbound frame waiting in writeCh, tagged with the
+// pending key it belongs to so serve can tell a still-wanted call from one
+// whose caller already gave up (see serve).
+type wsQueuedMsg struct {
+	key  string
+	data []byte
+}
+
+func newWebSocketTransport(rawURL string, bufferLimit int) *websocketTransport {
+	if bufferLimit <= 0 {
+		bufferLimit = wsMaxBufferedCalls
+	}
+
+	t := &websocketTransport{
+		rawURL:  rawURL,
+		pending: make(map[string]chan *JSONRPCResponse),
+		writeCh: make(chan wsQueuedMsg, bufferLimit),
+		closed:  make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// run owns the transport's connection lifecycle: dial, serve until the
+// connection drops, then reconnect with backoff, until Close is called.
+func (t *websocketTransport) run() {
+	attempt := 0
+	for {
+		conn, err := dialWebSocket(t.rawURL)
+		if err == nil {
+			attempt = 0
+			t.serve(conn)
+		}
+
+		select {
+		case <-t.closed:
+			return
+		default:
+		}
+
+		delay := wsReconnectBackoff[attempt]
+		if attempt < len(wsReconnectBackoff)-1 {
+			attempt++
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-t.closed:
+			return
+		}
+	}
+}
+
+// serve drains writeCh onto conn and delivers inbound frames until either
+// direction fails, at which point all pending calls get a -32000
+// "Connection lost" response and serve returns so run can reconnect. A queued message
+// is skipped, never written, if its pending entry is gone by the time it's
+// dequeued: that means the caller already gave up (RoundTrip's ctx.Done()
+// case) or a prior drop already failed it via failAllPending, so sending it
+// on a later connection would only re-execute a call nobody is waiting on.
+func (t *websocketTransport) serve(conn net.Conn) {
+	defer conn.Close()
+
+	readErrCh := make(chan error, 1)
+	go func() { readErrCh <- t.readLoop(conn) }()
+
+	for {
+		select {
+		case msg := <-t.writeCh:
+			t.mu.Lock()
+			_, stillWanted := t.pending[msg.key]
+			t.mu.Unlock()
+			if !stillWanted {
+				continue
+			}
+			if err := writeWSTextFrame(conn, msg.data); err != nil {
+				t.failAllPending(err)
+				return
+			}
+		case err := <-readErrCh:
+			t.failAllPending(err)
+			return
+		case <-t.closed:
+			return
+		}
+	}
+}
+
+func (t *websocketTransport) readLoop(conn net.Conn) error {
+	for {
+		payload, err := readWSFrame(conn)
+		if err != nil {
+			return err
+		}
+
+		var resp JSONRPCResponse
+		if err := json.Unmarshal(payload, &resp); err != nil {
+			continue
+		}
+		t.deliver(&resp)
+	}
+}
+
+func (t *websocketTransport) deliver(resp *JSONRPCResponse) {
+	key := idKey(resp.ID)
+
+	t.mu.Lock()
+	ch, ok := t.pending[key]
+	if ok {
+		delete(t.pending, key)
+	}
+	t.mu.Unlock()
+
+	if ok {
+		ch <- resp
+	}
+}
+
+// failAllPending delivers a -32000 "Connection lost" response to every
+// call currently awaiting a reply.
+func (t *websocketTransport) failAllPending(err error) {
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = make(map[string]chan *JSONRPCResponse)
+	t.mu.Unlock()
+
+	for _, ch := range pending {
+		ch <- &JSONRPCResponse{
+			JSONRPC: "2.0",
+			Error: &JSONRPCError{
+				Code:    -32000,
+				Message: "Connection lost",
+				Data:    err.Error(),
+			},
+		}
+	}
+}
+
+// RoundTrip sends req over the shared WebSocket connection (queuing it if
+// a reconnect is in progress) and waits for the correlated response.
+func (t *websocketTransport) RoundTrip(ctx context.Context, req *JSONRPCRequest) (*JSONRPCResponse, error) {
+	id := atomic.AddInt64(&t.nextID, 1)
+	wireReq := *req
+	wireReq.ID = id
+
+	data, err := json.Marshal(&wireReq)
+	if err != nil {
+		return nil, err
+	}
+
+	key := idKey(float64(id))
+	ch := make(chan *JSONRPCResponse, 1)
+	t.mu.Lock()
+	t.pending[key] = ch
+	t.mu.Unlock()
+
+	select {
+	case t.writeCh <- wsQueuedMsg{key: key, data: data}:
+	default:
+		t.mu.Lock()
+		delete(t.pending, key)
+		t.mu.Unlock()
+		return nil, fmt.Errorf("%w: send queue full", ErrConnectionLost)
+	}
+
+	select {
+	case resp := <-ch:
+		resp.ID = req.ID // restore the caller's original id
+		return resp, nil
+	case <-ctx.Done():
+		t.mu.Lock()
+		delete(t.pending, key)
+		t.mu.Unlock()
+		return nil, ctx.Err()
+	case <-t.closed:
+		return nil, fmt.Errorf("%w: transport closed", ErrConnectionLost)
+	}
+}
+
+func (t *websocketTransport) Stream(ctx context.Context) (io.ReadWriteCloser, error) {
+	return nil, fmt.Errorf("websocket transport manages its own connection; use RoundTrip")
+}
+
+// Close stops reconnect attempts and fails any in-flight calls.
+func (t *websocketTransport) Close() error {
+	t.once.Do(func() { close(t.closed) })
+	return nil
+}
+
+// wsConn wraps a dialed connection so frame reads go through the buffered
+// reader used for the handshake, preserving any bytes already buffered
+// past the HTTP upgrade response.
+type wsConn struct {
+	net.Conn
+	br *bufio.Reader
+}
+
+func (c *wsConn) Read(p []byte) (int, error) { return c.br.Read(p) }
+
+// dialWebSocket performs the TCP/TLS dial and RFC 6455 opening handshake
+// for rawURL (ws:// or wss://), returning a connection ready for framing.
+func dialWebSocket(rawURL string) (net.Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	host := u.Host
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		if u.Scheme == "wss" {
+			host = net.JoinHostPort(host, "443")
+		} else {
+			host = net.JoinHostPort(host, "80")
+		}
+	}
+
+	var conn net.Conn
+	if u.Scheme == "wss" {
+		conn, err = tls.Dial("tcp", host, &tls.Config{ServerName: u.Hostname()})
+	} else {
+		conn, err = net.Dial("tcp", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keyBytes := make([]byte, 16)
+	if _, err := rand.Read(keyBytes); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+
+	path := u.RequestURI()
+	if path == "" {
+		path = "/"
+	}
+
+	handshake := fmt.Sprintf(
+		"GET %s HTTP/1.1\r\nHost: %s\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Key: %s\r\nSec-WebSocket-Version: 13\r\n\r\n",
+		path, u.Host, key,
+	)
+	if _, err := conn.Write([]byte(handshake)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("websocket handshake failed: status %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != wsAcceptKey(key) {
+		conn.Close()
+		return nil, errors.New("websocket handshake failed: bad Sec-WebSocket-Accept")
+	}
+
+	return &wsConn{Conn: conn, br: br}, nil
+}
+
+func wsAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// readWSFrame reads a single, unfragmented WebSocket frame and returns its
+// payload, transparently consuming ping/pong control frames and treating a
+// close frame as io.EOF.
+func readWSFrame(r io.Reader) ([]byte, error) {
+	for {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, err
+		}
+
+		opcode := header[0] & 0x0f
+		masked := header[1]&0x80 != 0
+		length := int64(header[1] & 0x7f)
+
+		switch length {
+		case 126:
+			ext := make([]byte, 2)
+			if _, err := io.ReadFull(r, ext); err != nil {
+				return nil, err
+			}
+			length = int64(binary.BigEndian.Uint16(ext))
+		case 127:
+			ext := make([]byte, 8)
+			if _, err := io.ReadFull(r, ext); err != nil {
+				return nil, err
+			}
+			length = int64(binary.BigEndian.Uint64(ext))
+		}
+
+		var maskKey [4]byte
+		if masked {
+			if _, err := io.ReadFull(r, maskKey[:]); err != nil {
+				return nil, err
+			}
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil, err
+		}
+		if masked {
+			for i := range payload {
+				payload[i] ^= maskKey[i%4]
+			}
+		}
+
+		switch opcode {
+		case 0x8: // close
+			return nil, io.EOF
+		case 0x9, 0xA: // ping, pong
+			continue
+		default:
+			return payload, nil
+		}
+	}
+}
+
+// writeWSTextFrame writes payload as a single masked text frame, as
+// required of every client-to-server WebSocket frame.
+func writeWSTextFrame(w io.Writer, payload []byte) error {
+	length := len(payload)
+
+	var header []byte
+	switch {
+	case length <= 125:
+		header = []byte{0x81, byte(length) | 0x80}
+	case length <= 0xFFFF:
+		header = make([]byte, 4)
+		header[0] = 0x81
+		header[1] = 126 | 0x80
+		binary.BigEndian.PutUint16(header[2:], uint16(length))
+	default:
+		header = make([]byte, 10)
+		header[0] = 0x81
+		header[1] = 127 | 0x80
+		binary.BigEndian.PutUint64(header[2:], uint64(length))
+	}
+
+	var maskKey [4]byte
+	if _, err := rand.Read(maskKey[:]); err != nil {
+		return err
+	}
+
+	masked := make([]byte, length)
+	for i, b := range payload {
+		masked[i] = b ^ maskKey[i%4]
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(maskKey[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(masked)
+	return err
+}
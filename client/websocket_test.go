@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// acceptWSHandshake performs the server side of the RFC 6455 opening
+// handshake on an already-accepted connection and returns it ready for
+// framing, mirroring what dialWebSocket expects from a real server.
+func acceptWSHandshake(t *testing.T, conn net.Conn) net.Conn {
+	t.Helper()
+
+	br := bufio.NewReader(conn)
+	req, err := http.ReadRequest(br)
+	if err != nil {
+		t.Fatalf("Failed to read handshake request: %v", err)
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+
+	resp := fmt.Sprintf(
+		"HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		wsAcceptKey(key),
+	)
+	if _, err := conn.Write([]byte(resp)); err != nil {
+		t.Fatalf("Failed to write handshake response: %v", err)
+	}
+
+	return conn
+}
+
+// serveWSResponses accepts handshake connections on ln and, for each frame
+// it reads, writes back a JSONRPCResponse built by respond. It runs until
+// ln is closed.
+func serveWSResponses(t *testing.T, ln net.Listener, respond func(req JSONRPCRequest) (*JSONRPCResponse, bool)) {
+	t.Helper()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			conn = acceptWSHandshake(t, conn)
+
+			for {
+				payload, err := readWSFrame(conn)
+				if err != nil {
+					return
+				}
+				var req JSONRPCRequest
+				if err := json.Unmarshal(payload, &req); err != nil {
+					return
+				}
+				resp, ok := respond(req)
+				if !ok {
+					return
+				}
+				data, _ := json.Marshal(resp)
+				if err := writeWSTextFrame(conn, data); err != nil {
+					return
+				}
+			}
+		}(conn)
+	}
+}
+
+func TestWSFrame_WriteThenReadRoundTrips(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	want := []byte(`{"jsonrpc":"2.0","id":1,"result":"ok"}`)
+
+	go writeWSTextFrame(client, want)
+
+	got, err := readWSFrame(server)
+	if err != nil {
+		t.Fatalf("readWSFrame failed: %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("Expected payload %q, got %q", want, got)
+	}
+}
+
+func TestWSAcceptKey_MatchesRFC6455Example(t *testing.T) {
+	// Example key/accept pair from RFC 6455 section 1.3.
+	got := wsAcceptKey("dGhlIHNhbXBsZSBub25jZQ==")
+	want := "s3pPLMBiTxaQ9kYGzzhZRbK+xOo="
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestWebSocketTransport_RoundTripSucceeds(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	go serveWSResponses(t, ln, func(req JSONRPCRequest) (*JSONRPCResponse, bool) {
+		return &JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: req.Method}, true
+	})
+
+	transport := newWebSocketTransport("ws://"+ln.Addr().String(), 4)
+	defer transport.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := transport.RoundTrip(ctx, &JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "ping"})
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	if resp.Result != "ping" {
+		t.Errorf("Expected result 'ping', got %v", resp.Result)
+	}
+}
+
+func TestWebSocketTransport_ReconnectsAfterDropAndSucceeds(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	var connNum int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			n := atomic.AddInt32(&connNum, 1)
+			if n == 1 {
+				// Simulate the server dropping the connection after a call
+				// has actually been sent on it, but before answering.
+				conn = acceptWSHandshake(t, conn)
+				readWSFrame(conn)
+				conn.Close()
+				continue
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+				conn = acceptWSHandshake(t, conn)
+				for {
+					payload, err := readWSFrame(conn)
+					if err != nil {
+						return
+					}
+					var req JSONRPCRequest
+					json.Unmarshal(payload, &req)
+					data, _ := json.Marshal(&JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: "ok"})
+					if err := writeWSTextFrame(conn, data); err != nil {
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	transport := newWebSocketTransport("ws://"+ln.Addr().String(), 4)
+	defer transport.Close()
+
+	// The first call rides the connection that gets dropped before it's
+	// answered. A call in flight when the socket dies surfaces as a
+	// -32000 "Connection lost" response, not a Go error (see
+	// failAllPending) - the transport itself is still healthy.
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	firstResp, err := transport.RoundTrip(ctx, &JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "ping"})
+	if err != nil {
+		t.Fatalf("RoundTrip returned an unexpected Go error: %v", err)
+	}
+	if firstResp.Error == nil || firstResp.Error.Code != -32000 {
+		t.Fatalf("Expected -32000 \"Connection lost\" for the dropped first connection, got %v", firstResp.Error)
+	}
+
+	// ...but the transport must reconnect on its own and serve later calls.
+	deadline := time.Now().Add(2 * time.Second)
+	var resp *JSONRPCResponse
+	for time.Now().Before(deadline) {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		resp, err = transport.RoundTrip(ctx, &JSONRPCRequest{JSONRPC: "2.0", ID: 2, Method: "ping"})
+		cancel()
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("Expected RoundTrip to eventually succeed after reconnect, last error: %v", err)
+	}
+	if resp.Result != "ok" {
+		t.Errorf("Expected result 'ok', got %v", resp.Result)
+	}
+}
+
+func TestWebSocketTransport_AbandonedCallIsNotResentAfterReconnect(t *testing.T) {
+	// Free a port without anyone listening on it yet, so the transport's
+	// initial dials fail and the queued call below sits in writeCh
+	// undrained until the server comes up.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	transport := newWebSocketTransport("ws://"+addr, 4)
+	defer transport.Close()
+
+	// The caller gives up on this call (e.g. its own deadline fires) while
+	// the transport is still disconnected, so it's abandoned with no one
+	// listening for the response.
+	abandonCtx, abandonCancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer abandonCancel()
+	_, err = transport.RoundTrip(abandonCtx, &JSONRPCRequest{JSONRPC: "2.0", ID: 1, Method: "chargeCard"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the abandoned call to fail with context.DeadlineExceeded, got %v", err)
+	}
+
+	var mu sync.Mutex
+	var receivedMethods []string
+
+	ln2, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("Failed to re-listen on %s: %v", addr, err)
+	}
+	defer ln2.Close()
+	go serveWSResponses(t, ln2, func(req JSONRPCRequest) (*JSONRPCResponse, bool) {
+		mu.Lock()
+		receivedMethods = append(receivedMethods, req.Method)
+		mu.Unlock()
+		return &JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: "ok"}, true
+	})
+
+	// The transport should reconnect on its own and serve a fresh call...
+	deadline := time.Now().Add(3 * time.Second)
+	var resp *JSONRPCResponse
+	for time.Now().Before(deadline) {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		resp, err = transport.RoundTrip(ctx, &JSONRPCRequest{JSONRPC: "2.0", ID: 2, Method: "ping"})
+		cancel()
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("Expected RoundTrip to eventually succeed after reconnect, last error: %v", err)
+	}
+	if resp.Result != "ok" {
+		t.Errorf("Expected result 'ok', got %v", resp.Result)
+	}
+
+	// ...but the abandoned "chargeCard" call must never reach the server.
+	mu.Lock()
+	defer mu.Unlock()
+	for _, method := range receivedMethods {
+		if method == "chargeCard" {
+			t.Fatalf("Expected the abandoned call to never be sent, but the server received it: %v", receivedMethods)
+		}
+	}
+}
+
+func TestWebSocketTransport_BufferLimitRejectsExcessCalls(t *testing.T) {
+	// A listener that's opened then immediately closed frees an address
+	// nothing is listening on, so dials to it reliably fail and the
+	// transport never drains writeCh.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	transport := newWebSocketTransport("ws://"+addr, 2)
+	defer transport.Close()
+
+	for i := 0; i < 2; i++ {
+		go transport.RoundTrip(context.Background(), &JSONRPCRequest{JSONRPC: "2.0", ID: i, Method: "queued"})
+	}
+	time.Sleep(20 * time.Millisecond) // let the first two sends land in writeCh
+
+	_, err = transport.RoundTrip(context.Background(), &JSONRPCRequest{JSONRPC: "2.0", ID: 3, Method: "overflow"})
+	if !errors.Is(err, ErrConnectionLost) {
+		t.Fatalf("Expected ErrConnectionLost for a full send queue, got %v", err)
+	}
+}